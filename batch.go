@@ -0,0 +1,50 @@
+package jsonrpc2
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// batchWriter buffers the per-element responses of a single JSON-RPC batch
+// request and flushes them once every element has been accounted for.
+// Elements are dispatched concurrently (each gets its own rpc goroutine via
+// the normal ServeCodec machinery), so responses can arrive in any order;
+// batchWriter slots them back into their original position before flushing.
+type batchWriter struct {
+	mu        sync.Mutex
+	remaining int
+	responses []*json.RawMessage // nil entries are notifications
+	write     func([]*json.RawMessage) error
+}
+
+func newBatchWriter(n int, write func([]*json.RawMessage) error) *batchWriter {
+	return &batchWriter{
+		remaining: n,
+		responses: make([]*json.RawMessage, n),
+		write:     write,
+	}
+}
+
+// set records the response for element i (nil if it was a notification)
+// and, once every element has reported in, flushes the batch in its
+// original order.
+func (b *batchWriter) set(i int, resp *json.RawMessage) error {
+	b.mu.Lock()
+	b.responses[i] = resp
+	b.remaining--
+	done := b.remaining == 0
+	var out []*json.RawMessage
+	if done {
+		for _, r := range b.responses {
+			if r != nil {
+				out = append(out, r)
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	if !done {
+		return nil
+	}
+	return b.write(out)
+}
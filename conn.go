@@ -0,0 +1,220 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// Conn represents a single JSON-RPC 2.0 connection on which either side may
+// issue requests, receive responses, and send notifications. Use it in
+// place of a plain net.Conn (or any io.ReadWriteCloser) when the peer needs
+// to be called back into, e.g. from inside an RPC handler:
+//
+//	func (t *Arith) Add(rwc io.ReadWriteCloser, args *ArithArgs, reply *Reply) error {
+//		if conn, ok := rwc.(*Conn); ok {
+//			conn.Notify(context.Background(), "progress", args)
+//		}
+//		reply.C = args.A + args.B
+//		return nil
+//	}
+//
+// Conn satisfies io.ReadWriteCloser so it can be handed to NewServerCodec
+// and ServeConn exactly like a raw connection; requests from the peer are
+// dispatched through the same context-aware machinery as ServeConnContext
+// (so RegisterWithContext handlers get cancellation and subscriptions over
+// Conn too, falling back to the classic Register registry via
+// dispatchClassic for handlers registered the old way), while responses to
+// calls issued via Conn.Call are intercepted and delivered to the waiting
+// caller instead. Every per-request context carries c itself, retrievable
+// with ConnFromContext, so a handler can call back into the peer without
+// depending on the rwc argument being a *Conn.
+type Conn struct {
+	rwc io.ReadWriteCloser
+
+	pr *io.PipeReader // serverCodec reads inbound request frames from here
+	pw *io.PipeWriter // readLoop forwards them here
+
+	wmutex sync.Mutex // serializes writes to rwc
+
+	seq     uint64 // atomic; next outgoing request id
+	mutex   sync.Mutex
+	pending map[uint64]chan *clientResponse
+
+	closed chan struct{}
+}
+
+// NewConn wraps rwc as a bi-directional connection and starts serving
+// requests from the peer in the background.
+func NewConn(rwc io.ReadWriteCloser) *Conn {
+	pr, pw := io.Pipe()
+	c := &Conn{
+		rwc:     rwc,
+		pr:      pr,
+		pw:      pw,
+		pending: make(map[uint64]chan *clientResponse),
+		closed:  make(chan struct{}),
+	}
+	base := context.WithValue(context.Background(), connContextKey{}, c)
+	go serveCodecContext(NewServerCodec(c), base)
+	go c.readLoop()
+	return c
+}
+
+type clientRequest struct {
+	Version string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+	Id      *uint64     `json:"id,omitempty"`
+}
+
+type clientResponse struct {
+	Result json.RawMessage
+	Error  *Error
+}
+
+// Call issues method to the peer with params, blocks for a response, and
+// unmarshals its result into result (which may be nil). It returns the
+// peer's *Error verbatim if the call failed, or ctx.Err() if ctx is done
+// before a response arrives.
+func (c *Conn) Call(ctx context.Context, method string, params, result interface{}) error {
+	id := atomic.AddUint64(&c.seq, 1)
+	ch := make(chan *clientResponse, 1)
+
+	c.mutex.Lock()
+	c.pending[id] = ch
+	c.mutex.Unlock()
+
+	if err := c.send(clientRequest{Version: Version, Method: method, Params: params, Id: &id}); err != nil {
+		c.mutex.Lock()
+		delete(c.pending, id)
+		c.mutex.Unlock()
+		return err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result == nil || resp.Result == nil {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	case <-ctx.Done():
+		c.mutex.Lock()
+		delete(c.pending, id)
+		c.mutex.Unlock()
+		return ctx.Err()
+	case <-c.closed:
+		return errors.New("jsonrpc2: connection closed")
+	}
+}
+
+// Notify sends method to the peer with params and does not wait for a
+// response, per the JSON-RPC 2.0 notification convention of omitting "id".
+func (c *Conn) Notify(ctx context.Context, method string, params interface{}) error {
+	return c.send(clientRequest{Version: Version, Method: method, Params: params})
+}
+
+func (c *Conn) send(req clientRequest) error {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	_, err = c.Write(append(b, '\n'))
+	return err
+}
+
+// readLoop demultiplexes frames arriving from the peer: requests and
+// notifications are forwarded to the ServerCodec running over c's pipe,
+// while responses are matched against Call's pending map by id.
+func (c *Conn) readLoop() {
+	dec := json.NewDecoder(c.rwc)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			c.shutdown(err)
+			return
+		}
+
+		var probe struct {
+			Method *string          `json:"method"`
+			Id     *json.RawMessage `json:"id"`
+			Result json.RawMessage  `json:"result"`
+			Error  *Error           `json:"error"`
+		}
+		if err := json.Unmarshal(raw, &probe); err != nil || probe.Method != nil {
+			// A request/notification from the peer, or a malformed frame
+			// whose JSON-RPC error is best produced by the normal
+			// dispatch path: either way it belongs to the ServerCodec.
+			c.pw.Write(append(raw, '\n'))
+			continue
+		}
+		if probe.Id == nil {
+			continue
+		}
+
+		var id uint64
+		if err := json.Unmarshal(*probe.Id, &id); err != nil {
+			continue
+		}
+
+		c.mutex.Lock()
+		ch, ok := c.pending[id]
+		delete(c.pending, id)
+		c.mutex.Unlock()
+		if ok {
+			ch <- &clientResponse{Result: probe.Result, Error: probe.Error}
+		}
+	}
+}
+
+// shutdown unblocks the ServerCodec's pending Read and fails every call
+// still waiting for a response, after the peer connection is lost.
+func (c *Conn) shutdown(err error) {
+	c.pw.CloseWithError(err)
+
+	c.mutex.Lock()
+	for id, ch := range c.pending {
+		ch <- &clientResponse{Error: &Error{Code: ErrCodeInternal, Msg: ErrMsgInternal, Data: err.Error()}}
+		delete(c.pending, id)
+	}
+	c.mutex.Unlock()
+
+	close(c.closed)
+}
+
+// Read satisfies io.ReadWriteCloser for the benefit of NewServerCodec: it
+// reads request frames that readLoop has classified as belonging to us.
+func (c *Conn) Read(p []byte) (int, error) {
+	return c.pr.Read(p)
+}
+
+// Write satisfies io.ReadWriteCloser, sending raw JSON-RPC frames to the
+// peer. It is used both for Call/Notify and for responses written back by
+// the ServerCodec handling the peer's requests.
+func (c *Conn) Write(p []byte) (int, error) {
+	c.wmutex.Lock()
+	defer c.wmutex.Unlock()
+	return c.rwc.Write(p)
+}
+
+// Close closes the underlying connection.
+func (c *Conn) Close() error {
+	return c.rwc.Close()
+}
+
+type connContextKey struct{}
+
+// ConnFromContext returns the *Conn associated with ctx, if any. It lets a
+// handler registered with per-request context (see RegisterWithContext)
+// call back into the peer without relying on the rwc argument being a
+// *Conn.
+func ConnFromContext(ctx context.Context) (*Conn, bool) {
+	conn, ok := ctx.Value(connContextKey{}).(*Conn)
+	return conn, ok
+}
@@ -0,0 +1,171 @@
+package jsonrpc2
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+type connEcho struct{}
+type connEchoArgs struct{ Msg string }
+type connEchoReply struct{ Msg string }
+
+func (s *connEcho) Echo(ctx context.Context, args *connEchoArgs, reply *connEchoReply) error {
+	reply.Msg = args.Msg
+	return nil
+}
+
+// WhoAmI reports whether ConnFromContext found a *Conn for this call, so
+// TestConnFromContext can confirm NewConn wires connContextKey into the
+// per-request context it dispatches through.
+func (s *connEcho) WhoAmI(ctx context.Context, args *connEchoArgs, reply *connEchoReply) error {
+	if _, ok := ConnFromContext(ctx); ok {
+		reply.Msg = "conn"
+	}
+	return nil
+}
+
+func init() {
+	RegisterWithContext(new(connEcho))
+}
+
+// TestConnCallDispatchesContextRegistry exercises a *Conn calling into a peer
+// *Conn whose handler was registered via RegisterWithContext, confirming
+// NewConn now dispatches through serveCodecContext rather than the classic
+// ServeCodec loop only.
+func TestConnCallDispatchesContextRegistry(t *testing.T) {
+	c1, c2 := net.Pipe()
+	client := NewConn(c1)
+	defer client.Close()
+	server := NewConn(c2)
+	defer server.Close()
+
+	var reply connEchoReply
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := client.Call(ctx, "connEcho.Echo", &connEchoArgs{Msg: "hi"}, &reply); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if reply.Msg != "hi" {
+		t.Errorf("reply.Msg = %q, want %q", reply.Msg, "hi")
+	}
+}
+
+// TestConnCallDispatchesClassicRegistry exercises the classic-registry
+// fallback (dispatchClassic) over a *Conn: Arith is registered with the
+// classic Register, not RegisterWithContext (see server_test.go's init), so
+// a *Conn must still reach it rather than answering "method not found".
+func TestConnCallDispatchesClassicRegistry(t *testing.T) {
+	c1, c2 := net.Pipe()
+	client := NewConn(c1)
+	defer client.Close()
+	server := NewConn(c2)
+	defer server.Close()
+
+	var reply int
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := client.Call(ctx, "Arith.Sub", []int{10, 3}, &reply); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if reply != 7 {
+		t.Errorf("reply = %d, want 7", reply)
+	}
+}
+
+// TestConnPipelinedClassicCallsDoNotCorrupt is a regression test for a data
+// race in dispatchClassic (see context.go): two classic-registry calls
+// (Arith, registered only via the classic Register, not
+// RegisterWithContext) issued back-to-back over the same Conn used to race
+// on the codec's shared scratch request state, since dispatchClassic handed
+// the whole classic dispatch - including reading the request body - to a
+// goroutine before the server's read loop moved on to the next pipelined
+// request.
+func TestConnPipelinedClassicCallsDoNotCorrupt(t *testing.T) {
+	c1, c2 := net.Pipe()
+	client := NewConn(c1)
+	defer client.Close()
+	server := NewConn(c2)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	type result struct {
+		name string
+		got  int
+		err  error
+	}
+	results := make(chan result, 2)
+
+	go func() {
+		var reply Reply
+		err := client.Call(ctx, "Arith.Add", &ArithArgs{A: 1, B: 2}, &reply)
+		results <- result{"Add", reply.C, err}
+	}()
+	go func() {
+		var reply int
+		err := client.Call(ctx, "Arith.Sub", []int{10, 3}, &reply)
+		results <- result{"Sub", reply, err}
+	}()
+
+	want := map[string]int{"Add": 3, "Sub": 7}
+	for i := 0; i < 2; i++ {
+		r := <-results
+		if r.err != nil {
+			t.Fatalf("%s: %v", r.name, r.err)
+		}
+		if r.got != want[r.name] {
+			t.Errorf("%s = %d, want %d", r.name, r.got, want[r.name])
+		}
+	}
+}
+
+// TestConnFromContext confirms a handler invoked over a *Conn can retrieve
+// that Conn back via ConnFromContext, e.g. to call back into the peer.
+func TestConnFromContext(t *testing.T) {
+	c1, c2 := net.Pipe()
+	client := NewConn(c1)
+	defer client.Close()
+	server := NewConn(c2)
+	defer server.Close()
+
+	var reply connEchoReply
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := client.Call(ctx, "connEcho.WhoAmI", &connEchoArgs{}, &reply); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if reply.Msg != "conn" {
+		t.Error("handler's ConnFromContext found no *Conn, want the server's Conn")
+	}
+
+	if _, ok := ConnFromContext(context.Background()); ok {
+		t.Error("ConnFromContext(context.Background()) = ok, want !ok")
+	}
+}
+
+// TestConnNotify sends a notification from one Conn to another and confirms
+// it doesn't block waiting for a response.
+func TestConnNotify(t *testing.T) {
+	c1, c2 := net.Pipe()
+	client := NewConn(c1)
+	defer client.Close()
+	server := NewConn(c2)
+	defer server.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Notify(context.Background(), "connEcho.Echo", &connEchoArgs{Msg: "hi"})
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Notify: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Notify blocked")
+	}
+}
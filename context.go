@@ -0,0 +1,310 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// CancelMethod is the notification method name the server recognizes as a
+// request to cancel an in-flight call by id, mirroring the "$/cancelRequest"
+// convention used by LSP-style servers. Reassign it before serving starts
+// to use a different method name.
+var CancelMethod = "$/cancelRequest"
+
+type cancelParams struct {
+	Id json.RawMessage `json:"id"`
+}
+
+var (
+	ctxMu        sync.Mutex
+	ctxServices  = map[string]reflect.Value{}
+	ctxArgType   = map[string]reflect.Type{}
+	ctxReplyType = map[string]reflect.Type{}
+)
+
+var ctxType = reflect.TypeOf((*context.Context)(nil)).Elem()
+var errType = reflect.TypeOf((*error)(nil)).Elem()
+
+// RegisterWithContext registers the exported methods of rcvr whose
+// signature is func(context.Context, *ArgsType, *ReplyType) error, making
+// them callable as "<TypeName>.<MethodName>" over ServeConnContext/
+// ServeCodecContext. Unlike Register, handlers registered this way receive
+// a context.Context that is cancelled when the client sends a matching
+// CancelMethod notification for the same request id, or when the
+// connection closes, so long-running calls can abort cleanly.
+func RegisterWithContext(rcvr interface{}) error {
+	v := reflect.ValueOf(rcvr)
+	t := reflect.TypeOf(rcvr)
+	name := t.Elem().Name()
+
+	ctxMu.Lock()
+	defer ctxMu.Unlock()
+
+	registered := 0
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		if m.PkgPath != "" {
+			continue // unexported
+		}
+		mt := m.Type
+		if mt.NumIn() != 4 || mt.NumOut() != 1 {
+			continue
+		}
+		if mt.In(1) != ctxType || mt.Out(0) != errType {
+			continue
+		}
+		argType, replyType := mt.In(2), mt.In(3)
+		if argType.Kind() != reflect.Ptr || replyType.Kind() != reflect.Ptr {
+			continue
+		}
+
+		key := name + "." + m.Name
+		ctxServices[key] = v.Method(i)
+		ctxArgType[key] = argType.Elem()
+		ctxReplyType[key] = replyType.Elem()
+		registered++
+	}
+
+	if registered == 0 {
+		return fmt.Errorf("rpc: type %s has no exported methods of suitable type for RegisterWithContext", name)
+	}
+	return nil
+}
+
+// lookupContextMethod returns the method registered under name via
+// RegisterWithContext, along with its args/reply types, for dispatchers
+// that run over the ctxServices registry (ServeCodecContext, the HTTP and
+// WebSocket transports).
+func lookupContextMethod(name string) (method reflect.Value, argType, replyType reflect.Type, ok bool) {
+	ctxMu.Lock()
+	defer ctxMu.Unlock()
+	method, ok = ctxServices[name]
+	if !ok {
+		return
+	}
+	return method, ctxArgType[name], ctxReplyType[name], true
+}
+
+// ServeConnContext is like ServeConn, but dispatches to methods registered
+// via RegisterWithContext, falling back to the Register registry (see
+// dispatchClassic) for methods not found there.
+func ServeConnContext(conn io.ReadWriteCloser) {
+	ServeCodecContext(NewServerCodec(conn))
+}
+
+// ServeCodecContext runs the context-aware RPC server on a single codec. It
+// blocks, creating a fresh context.Context for every incoming request and
+// cancelling it either when the client sends a CancelMethod notification
+// naming that request's id, or when the connection is closed.
+func ServeCodecContext(codec ServerCodec) {
+	serveCodecContext(codec, context.Background())
+}
+
+// serveCodecContext is ServeCodecContext with the caller supplying the base
+// context every per-request context descends from, so a value placed on it
+// (e.g. Conn's connContextKey, see NewConn) reaches every handler. base is
+// otherwise treated exactly like context.Background would be: it is never
+// itself cancelled by this function, only derived from.
+func serveCodecContext(codec ServerCodec, base context.Context) {
+	base, cancelAll := context.WithCancel(base)
+	defer cancelAll()
+
+	// Subscriptions outlive any single request, so the Notifier is tied to
+	// the connection: one per ServeCodecContext call, attached to every
+	// per-request context below.
+	var notifier *Notifier
+	if nw, ok := codec.(notificationWriter); ok {
+		notifier = newNotifier(nw)
+		defer notifier.closeAll()
+	}
+
+	var mu sync.Mutex
+	handling := make(map[uint64]context.CancelFunc)
+	defer func() {
+		mu.Lock()
+		for _, cancel := range handling {
+			cancel()
+		}
+		mu.Unlock()
+	}()
+
+	for {
+		req := new(Request)
+		if err := codec.ReadRequestHeader(req); err != nil {
+			if err != io.EOF {
+				codec.WriteResponse(&Response{Error: toRequestError(err)}, nil)
+			}
+			return
+		}
+
+		if req.ServiceMethod == CancelMethod {
+			var params cancelParams
+			codec.ReadRequestBody(&params)
+			var id uint64
+			if json.Unmarshal(params.Id, &id) == nil {
+				mu.Lock()
+				if cancel, ok := handling[id]; ok {
+					cancel()
+				}
+				mu.Unlock()
+			}
+			// CancelMethod itself was read like any other request and got
+			// its own seq in codec's pending/starts (and batches, if it
+			// arrived as part of one); resolve it like the notification it
+			// is so that bookkeeping doesn't leak and, if it shared a
+			// batch, the batch's WaitGroup-style remaining count can still
+			// reach zero and flush.
+			codec.WriteResponse(&Response{Seq: req.Seq}, nil)
+			continue
+		}
+
+		method, argType, replyType, ok := lookupContextMethod(req.ServiceMethod)
+		if !ok {
+			// Not every service is registered with RegisterWithContext; fall
+			// back to the classic Register/ServeCodec registry so the same
+			// Register(new(Arith)) call that exposes a service over stdio
+			// keeps working here too, instead of always answering "method
+			// not found".
+			dispatchClassic(codec, req)
+			continue
+		}
+
+		argv := reflect.New(argType)
+		if err := codec.ReadRequestBody(argv.Interface()); err != nil {
+			codec.WriteResponse(&Response{Seq: req.Seq, Error: toRequestError(err)}, nil)
+			continue
+		}
+		replyv := reflect.New(replyType)
+
+		reqCtx, cancel := context.WithCancel(base)
+		if notifier != nil {
+			reqCtx = context.WithValue(reqCtx, notifierContextKey{}, notifier)
+		}
+		mu.Lock()
+		handling[req.Seq] = cancel
+		mu.Unlock()
+
+		go func(seq uint64) {
+			defer func() {
+				mu.Lock()
+				delete(handling, seq)
+				mu.Unlock()
+				cancel()
+			}()
+
+			results := method.Call([]reflect.Value{reflect.ValueOf(reqCtx), argv, replyv})
+			var rpcErr interface{}
+			if e, _ := results[0].Interface().(error); e != nil {
+				rpcErr = e
+			}
+
+			// A *_Subscribe call returns the subscription itself; the
+			// client only ever sees its ID as the RPC result.
+			if strings.HasSuffix(req.ServiceMethod, "Subscribe") {
+				if sub, ok := replyv.Interface().(*Subscription); ok {
+					codec.WriteResponse(&Response{Seq: seq, Error: rpcErr}, sub.ID)
+					return
+				}
+			}
+
+			codec.WriteResponse(&Response{Seq: seq, Error: rpcErr}, replyv.Elem().Interface())
+		}(req.Seq)
+	}
+}
+
+// classicRelay replays a single already-read Request, with its body already
+// captured into raw, through the classic Register/ServeCodec machinery, for
+// a method that lookupContextMethod didn't find in ctxServices.
+// ReadRequestHeader hands back req exactly once and reports io.EOF on the
+// next call, so ServeCodec's loop dispatches that one request and returns;
+// ReadRequestBody unmarshals from the already-captured raw/rawErr rather
+// than calling back into the real codec (see dispatchClassic for why), while
+// WriteResponse and ReadWriteCloser forward to the real codec so the
+// response (and any rwc the classic handler expects) still reach the client
+// normally. Register/ServeCodec dispatch the call itself on its own
+// goroutine, so done is closed from WriteResponse rather than after
+// ServeCodec returns, letting callers that need it block until the response
+// actually went out.
+type classicRelay struct {
+	codec  ServerCodec
+	req    *Request
+	read   bool
+	raw    json.RawMessage
+	rawErr error
+	done   chan struct{}
+}
+
+func (c *classicRelay) ReadRequestHeader(r *Request) error {
+	if c.read {
+		return io.EOF
+	}
+	c.read = true
+	*r = *c.req
+	return nil
+}
+
+func (c *classicRelay) ReadRequestBody(x interface{}) error {
+	if x == nil {
+		return nil
+	}
+	if c.rawErr != nil {
+		return c.rawErr
+	}
+	return json.Unmarshal(c.raw, x)
+}
+
+func (c *classicRelay) WriteResponse(r *Response, x interface{}) error {
+	err := c.codec.WriteResponse(r, x)
+	close(c.done)
+	return err
+}
+
+func (c *classicRelay) Close() error {
+	return nil
+}
+
+// ReadWriteCloser forwards to the real codec's, if it exposes one, so a
+// classic handler doing rwc.(*Conn) still sees the genuine connection
+// rather than this relay.
+func (c *classicRelay) ReadWriteCloser() io.ReadWriteCloser {
+	if rwc, ok := c.codec.(interface{ ReadWriteCloser() io.ReadWriteCloser }); ok {
+		return rwc.ReadWriteCloser()
+	}
+	return nil
+}
+
+// dispatchClassic falls back to the original Register/ServeCodec registry
+// for req, whose method wasn't found in ctxServices. codec's scratch state
+// (server.go's serverCodec.req) is reused, unguarded, by the very next
+// ReadRequestHeader call, so req's body must be drained off it before
+// dispatchClassic returns control to the caller's read loop — hence the
+// codec.ReadRequestBody call below happens synchronously here, on the
+// caller's own goroutine, rather than inside the relay's goroutine where a
+// pipelined or batched request right behind it could race to reset that
+// same state first. Only the actual method invocation and response write
+// run on the returned goroutine; callers that need to know when that's
+// finished (e.g. HTTP's one-shot WaitGroup) can wait on the returned
+// channel, which closes once the response has been written.
+func dispatchClassic(codec ServerCodec, req *Request) <-chan struct{} {
+	var raw json.RawMessage
+	rawErr := codec.ReadRequestBody(&raw)
+
+	done := make(chan struct{})
+	go ServeCodec(&classicRelay{codec: codec, req: req, raw: raw, rawErr: rawErr, done: done})
+	return done
+}
+
+// toRequestError normalizes an error from ReadRequestHeader/ReadRequestBody
+// into a JSON-RPC error object, wrapping anything that isn't already one as
+// a parse error.
+func toRequestError(err error) interface{} {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	return &Error{Code: ErrCodeParse, Msg: ErrMsgParse, Data: "server cannot decode request: " + err.Error()}
+}
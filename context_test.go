@@ -0,0 +1,159 @@
+package jsonrpc2
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type ctxArgs struct{}
+type ctxReply struct{}
+
+type ctxService struct{}
+
+func (s *ctxService) Wait(ctx context.Context, args *ctxArgs, reply *ctxReply) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *ctxService) Echo(ctx context.Context, args *ctxArgs, reply *ctxReply) error {
+	return nil
+}
+
+func init() {
+	RegisterWithContext(new(ctxService))
+}
+
+// TestCancelRequestRespondsToCaller ensures a $/cancelRequest notification
+// cancels the in-flight ctxService.Wait call it names, so that call's
+// caller receives its error response instead of waiting forever.
+func TestCancelRequestRespondsToCaller(t *testing.T) {
+	cli, srv := net.Pipe()
+	defer cli.Close()
+	go ServeConnContext(srv)
+
+	go cli.Write([]byte(`{"jsonrpc":"2.0","method":"ctxService.Wait","params":{},"id":1}` + "\n"))
+	time.Sleep(20 * time.Millisecond)
+	go cli.Write([]byte(`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":1}}` + "\n"))
+
+	r := bufio.NewReader(cli)
+	found := false
+	for i := 0; i < 2; i++ {
+		s, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("ReadString: %v", err)
+		}
+		if strings.Contains(s, `"error"`) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Fatal("expected the cancelled call's error response")
+	}
+}
+
+// TestCancelRequestInBatchDoesNotHangBatch is a regression test: a
+// $/cancelRequest arriving as one element of a batch must still resolve its
+// own batchWriter slot, or the batch's remaining count never reaches zero
+// and the whole batch (including its other, legitimately-answered
+// elements) is silently dropped.
+func TestCancelRequestInBatchDoesNotHangBatch(t *testing.T) {
+	cli, srv := net.Pipe()
+	defer cli.Close()
+	go ServeConnContext(srv)
+
+	batch := `[
+		{"jsonrpc":"2.0","method":"ctxService.Echo","params":{},"id":"a"},
+		{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":999}}
+	]`
+	go cli.Write([]byte(batch + "\n"))
+
+	done := make(chan string, 1)
+	go func() {
+		s, err := bufio.NewReader(cli).ReadString('\n')
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		done <- strings.TrimSpace(s)
+	}()
+
+	select {
+	case s := <-done:
+		if !strings.Contains(s, `"id":"a"`) {
+			t.Errorf("batch response = %s, want an element for id \"a\"", s)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("batch never flushed: cancel notification left it stuck")
+	}
+}
+
+// TestBatchWithMultipleClassicMethodsDoesNotCorrupt is a regression test for
+// a data race in dispatchClassic: a batch with more than one Register-only
+// (non-RegisterWithContext) method used to hand the whole classic dispatch,
+// including reading the request body, off to a goroutine before
+// serveCodecContext's loop moved on to the batch's next element - so the
+// next element's ReadRequestHeader could reset the codec's shared scratch
+// request out from under the first element's still-pending
+// ReadRequestBody, corrupting params or producing a bogus parse error. Both
+// elements here are Arith methods registered only via the classic Register
+// (see server_test.go's init), forcing both through dispatchClassic.
+func TestBatchWithMultipleClassicMethodsDoesNotCorrupt(t *testing.T) {
+	cli, srv := net.Pipe()
+	defer cli.Close()
+	go ServeConnContext(srv)
+
+	batch := `[
+		{"jsonrpc":"2.0","method":"Arith.Add","params":{"A":1,"B":2},"id":"add"},
+		{"jsonrpc":"2.0","method":"Arith.Sub","params":[10,3],"id":"sub"}
+	]`
+	go cli.Write([]byte(batch + "\n"))
+
+	done := make(chan string, 1)
+	go func() {
+		s, err := bufio.NewReader(cli).ReadString('\n')
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		done <- strings.TrimSpace(s)
+	}()
+
+	var line string
+	select {
+	case line = <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("batch never flushed")
+	}
+
+	var elems []struct {
+		Id     string          `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  interface{}     `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(line), &elems); err != nil {
+		t.Fatalf("unmarshal batch response %s: %v", line, err)
+	}
+	if len(elems) != 2 {
+		t.Fatalf("got %d batch elements, want 2: %s", len(elems), line)
+	}
+
+	results := make(map[string]json.RawMessage, 2)
+	for _, e := range elems {
+		if e.Error != nil {
+			t.Fatalf("element %s: unexpected error %v", e.Id, e.Error)
+		}
+		results[e.Id] = e.Result
+	}
+	if got := string(results["add"]); got != `{"C":3}` {
+		t.Errorf("Arith.Add result = %s, want {\"C\":3}", got)
+	}
+	if got := string(results["sub"]); got != "7" {
+		t.Errorf("Arith.Sub result = %s, want 7", got)
+	}
+}
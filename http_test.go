@@ -0,0 +1,73 @@
+package jsonrpc2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestHTTPHandlerDispatchesClassicRegistry exercises the fallback added for
+// chunk0-5: Arith is registered with the classic Register, not
+// RegisterWithContext (see server_test.go's init), so NewHTTPHandler must
+// still find and invoke it via dispatchClassic rather than answering
+// "method not found" for every request outside ctxServices.
+func TestHTTPHandlerDispatchesClassicRegistry(t *testing.T) {
+	srv := httptest.NewServer(NewHTTPHandler())
+	defer srv.Close()
+
+	body := `{"jsonrpc": "2.0", "method": "Arith.Sub", "params": [10, 3], "id": 1}`
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	var out [512]byte
+	n, _ := resp.Body.Read(out[:])
+	got := strings.TrimSpace(string(out[:n]))
+	want := `{"jsonrpc":"2.0","id":1,"result":7}`
+	if got != want {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+}
+
+// TestHTTPHandlerBadRequestOnMalformedBody ensures a body that never makes
+// it to RPC dispatch (invalid JSON) is reported as 400, not 200.
+func TestHTTPHandlerBadRequestOnMalformedBody(t *testing.T) {
+	srv := httptest.NewServer(NewHTTPHandler())
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader(`{"jsonrpc": "2.0", "method"`))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+// TestHTTPHandlerOKOnUnknownMethod ensures a well-formed request for a
+// method that exists in neither registry still comes back as a normal 200
+// JSON-RPC error, not a 400 — the request itself wasn't malformed.
+func TestHTTPHandlerOKOnUnknownMethod(t *testing.T) {
+	srv := httptest.NewServer(NewHTTPHandler())
+	defer srv.Close()
+
+	body := `{"jsonrpc": "2.0", "method": "Arith.DoesNotExist", "params": [], "id": 1}`
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
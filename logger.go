@@ -0,0 +1,77 @@
+package jsonrpc2
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Logger receives structured events for the lifecycle of each JSON-RPC
+// request handled by a ServerCodec, so the library can be wired into a
+// production observability stack instead of logging nothing by default.
+type Logger interface {
+	// LogRequest is called once a request has been decoded, with its
+	// method name, raw id (the JSON literal "null" for a notification),
+	// and raw params.
+	LogRequest(method string, id, params json.RawMessage)
+
+	// LogResponse is called just before a request's response is written
+	// (or, for a batch element, handed to its batch), with its raw id, how
+	// long it took from LogRequest to here, and the *Error it failed with,
+	// if any.
+	LogResponse(id json.RawMessage, duration time.Duration, err *Error)
+
+	// LogError is called for failures reported outside LogResponse: a
+	// request whose params fail to decode, and an error response written
+	// for a frame that never got a sequence number, e.g. a malformed frame
+	// that forces the connection closed.
+	LogError(err error)
+}
+
+// noopLogger is the default Logger: it discards every event.
+type noopLogger struct{}
+
+func (noopLogger) LogRequest(string, json.RawMessage, json.RawMessage) {}
+func (noopLogger) LogResponse(json.RawMessage, time.Duration, *Error)  {}
+func (noopLogger) LogError(error)                                      {}
+
+// StdLogger is a Logger backed by the standard library's log.Logger,
+// emitting one DEBUG-prefixed line per event.
+type StdLogger struct {
+	*log.Logger
+}
+
+// NewStdLogger returns a StdLogger writing through l.
+func NewStdLogger(l *log.Logger) *StdLogger {
+	return &StdLogger{Logger: l}
+}
+
+func (l *StdLogger) LogRequest(method string, id, params json.RawMessage) {
+	l.Printf("DEBUG jsonrpc2: request method=%s id=%s params=%s", method, id, params)
+}
+
+func (l *StdLogger) LogResponse(id json.RawMessage, duration time.Duration, err *Error) {
+	if err != nil {
+		l.Printf("DEBUG jsonrpc2: response id=%s duration=%s error=%v", id, duration, err)
+		return
+	}
+	l.Printf("DEBUG jsonrpc2: response id=%s duration=%s", id, duration)
+}
+
+func (l *StdLogger) LogError(err error) {
+	l.Printf("DEBUG jsonrpc2: error=%v", err)
+}
+
+// asError converts the interface{}-typed error carried on a Response (or a
+// batch element's error) into an *Error for Logger, wrapping anything that
+// isn't already one so callers never have to type-switch themselves.
+func asError(v interface{}) *Error {
+	if v == nil {
+		return nil
+	}
+	if e, ok := v.(*Error); ok {
+		return e
+	}
+	return &Error{Code: ErrCodeInternal, Msg: fmt.Sprint(v)}
+}
@@ -0,0 +1,77 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNoopLoggerDiscardsEverything(t *testing.T) {
+	var l noopLogger
+	// These must simply not panic; noopLogger has no observable state.
+	l.LogRequest("Arith.Add", null, null)
+	l.LogResponse(null, time.Millisecond, nil)
+	l.LogResponse(null, time.Millisecond, &Error{Code: ErrCodeInternal, Msg: "boom"})
+	l.LogError(nil)
+}
+
+func TestStdLoggerLogRequest(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewStdLogger(log.New(&buf, "", 0))
+
+	id := json.RawMessage(`1`)
+	params := json.RawMessage(`[1,2]`)
+	l.LogRequest("Arith.Add", id, params)
+
+	out := buf.String()
+	for _, want := range []string{"Arith.Add", "id=1", "params=[1,2]"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("LogRequest output %q missing %q", out, want)
+		}
+	}
+}
+
+func TestStdLoggerLogResponse(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewStdLogger(log.New(&buf, "", 0))
+
+	l.LogResponse(json.RawMessage(`1`), 5*time.Millisecond, nil)
+	if strings.Contains(buf.String(), "error") {
+		t.Errorf("LogResponse with no error logged %q, want no \"error\"", buf.String())
+	}
+
+	buf.Reset()
+	l.LogResponse(json.RawMessage(`2`), 5*time.Millisecond, &Error{Code: ErrCodeInternal, Msg: "boom"})
+	if !strings.Contains(buf.String(), "boom") {
+		t.Errorf("LogResponse with error logged %q, want it to mention %q", buf.String(), "boom")
+	}
+}
+
+func TestStdLoggerLogError(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewStdLogger(log.New(&buf, "", 0))
+
+	l.LogError(&Error{Code: ErrCodeParse, Msg: "bad json"})
+	if !strings.Contains(buf.String(), "bad json") {
+		t.Errorf("LogError output %q missing %q", buf.String(), "bad json")
+	}
+}
+
+func TestAsError(t *testing.T) {
+	if got := asError(nil); got != nil {
+		t.Errorf("asError(nil) = %v, want nil", got)
+	}
+
+	want := &Error{Code: ErrCodeInternal, Msg: "boom"}
+	if got := asError(want); got != want {
+		t.Errorf("asError(*Error) = %v, want the same *Error back", got)
+	}
+
+	got := asError("boom")
+	if got == nil || got.Msg != "boom" {
+		t.Errorf("asError(string) = %v, want an *Error wrapping it", got)
+	}
+}
@@ -0,0 +1,133 @@
+package jsonrpc2
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// notificationWriter is satisfied by a ServerCodec that can push an
+// out-of-band server-to-client notification alongside ordinary responses.
+// serverCodec implements it; Notifier uses it to deliver subscription
+// updates.
+type notificationWriter interface {
+	WriteNotification(method string, params interface{}) error
+}
+
+// Subscription identifies a single pub/sub subscription created via
+// Notifier.CreateSubscription. Its ID is opaque and unique within the
+// connection that created it.
+type Subscription struct {
+	ID string
+
+	namespace string
+	notifier  *Notifier
+	once      sync.Once
+	err       chan struct{}
+}
+
+// Unsubscribe removes the subscription so no further Notify calls for its
+// ID are delivered, and closes the channel returned by Err.
+func (s *Subscription) Unsubscribe() {
+	s.notifier.Unsubscribe(s.ID)
+}
+
+// Err returns a channel that's closed once the subscription is removed,
+// either explicitly via Unsubscribe or because the connection closed.
+func (s *Subscription) Err() <-chan struct{} {
+	return s.err
+}
+
+// Notifier lets a handler registered via RegisterWithContext create
+// subscriptions and push asynchronous updates for them to the client,
+// independent of the request/response cycle. Retrieve the Notifier for the
+// current connection with NotifierFromContext.
+type Notifier struct {
+	codec notificationWriter
+
+	mu   sync.Mutex
+	seq  uint64
+	subs map[string]*Subscription
+}
+
+func newNotifier(codec notificationWriter) *Notifier {
+	return &Notifier{codec: codec, subs: make(map[string]*Subscription)}
+}
+
+// CreateSubscription allocates a new subscription with a fresh opaque ID.
+// namespace is used to build the "<namespace>_subscription" method name
+// that Notify sends updates under.
+func (n *Notifier) CreateSubscription(namespace string) *Subscription {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.seq++
+	sub := &Subscription{
+		ID:        fmt.Sprintf("0x%x", n.seq),
+		namespace: namespace,
+		notifier:  n,
+		err:       make(chan struct{}),
+	}
+	n.subs[sub.ID] = sub
+	return sub
+}
+
+type subscriptionParams struct {
+	Subscription string      `json:"subscription"`
+	Result       interface{} `json:"result"`
+}
+
+// Notify pushes data to the client as a
+// {"jsonrpc":"2.0","method":"<ns>_subscription","params":{"subscription":"<id>","result":...}}
+// notification for the subscription identified by id. It is a no-op if
+// that subscription has already been unsubscribed.
+func (n *Notifier) Notify(id string, data interface{}) error {
+	n.mu.Lock()
+	sub, ok := n.subs[id]
+	n.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	return n.codec.WriteNotification(sub.namespace+"_subscription", subscriptionParams{
+		Subscription: id,
+		Result:       data,
+	})
+}
+
+// Unsubscribe removes the subscription identified by id, if it still
+// exists, and closes its error channel.
+func (n *Notifier) Unsubscribe(id string) {
+	n.mu.Lock()
+	sub, ok := n.subs[id]
+	if ok {
+		delete(n.subs, id)
+	}
+	n.mu.Unlock()
+
+	if ok {
+		sub.once.Do(func() { close(sub.err) })
+	}
+}
+
+// closeAll unsubscribes every live subscription, used once the connection
+// that owns this Notifier has closed.
+func (n *Notifier) closeAll() {
+	n.mu.Lock()
+	subs := n.subs
+	n.subs = make(map[string]*Subscription)
+	n.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.once.Do(func() { close(sub.err) })
+	}
+}
+
+type notifierContextKey struct{}
+
+// NotifierFromContext returns the *Notifier for the connection that ctx's
+// request arrived on, if any. ServeCodecContext attaches one automatically.
+func NotifierFromContext(ctx context.Context) (*Notifier, bool) {
+	n, ok := ctx.Value(notifierContextKey{}).(*Notifier)
+	return n, ok
+}
@@ -0,0 +1,135 @@
+package jsonrpc2
+
+import (
+	"context"
+	"testing"
+)
+
+// fakeNotificationWriter records every WriteNotification call made against
+// it, so tests can assert on what a Notifier actually sent.
+type fakeNotificationWriter struct {
+	method string
+	params interface{}
+	calls  int
+}
+
+func (f *fakeNotificationWriter) WriteNotification(method string, params interface{}) error {
+	f.method = method
+	f.params = params
+	f.calls++
+	return nil
+}
+
+func TestNotifierCreateSubscription(t *testing.T) {
+	n := newNotifier(&fakeNotificationWriter{})
+
+	sub1 := n.CreateSubscription("newHeads")
+	sub2 := n.CreateSubscription("newHeads")
+
+	if sub1.ID == "" {
+		t.Fatal("CreateSubscription gave an empty ID")
+	}
+	if sub1.ID == sub2.ID {
+		t.Fatalf("two subscriptions got the same ID %q", sub1.ID)
+	}
+}
+
+func TestNotifierNotify(t *testing.T) {
+	w := &fakeNotificationWriter{}
+	n := newNotifier(w)
+	sub := n.CreateSubscription("newHeads")
+
+	if err := n.Notify(sub.ID, 42); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if w.calls != 1 {
+		t.Fatalf("WriteNotification called %d times, want 1", w.calls)
+	}
+	if w.method != "newHeads_subscription" {
+		t.Errorf("method = %q, want %q", w.method, "newHeads_subscription")
+	}
+	params, ok := w.params.(subscriptionParams)
+	if !ok {
+		t.Fatalf("params = %#v, want a subscriptionParams", w.params)
+	}
+	if params.Subscription != sub.ID || params.Result != 42 {
+		t.Errorf("params = %+v, want subscription=%s result=42", params, sub.ID)
+	}
+}
+
+// TestNotifierNotifyAfterUnsubscribe confirms Notify is a silent no-op for a
+// subscription that's already gone, rather than erroring.
+func TestNotifierNotifyAfterUnsubscribe(t *testing.T) {
+	w := &fakeNotificationWriter{}
+	n := newNotifier(w)
+	sub := n.CreateSubscription("newHeads")
+	n.Unsubscribe(sub.ID)
+
+	if err := n.Notify(sub.ID, 42); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if w.calls != 0 {
+		t.Errorf("WriteNotification called %d times, want 0", w.calls)
+	}
+}
+
+func TestSubscriptionUnsubscribeClosesErr(t *testing.T) {
+	n := newNotifier(&fakeNotificationWriter{})
+	sub := n.CreateSubscription("newHeads")
+
+	select {
+	case <-sub.Err():
+		t.Fatal("Err() closed before Unsubscribe")
+	default:
+	}
+
+	sub.Unsubscribe()
+
+	select {
+	case <-sub.Err():
+	default:
+		t.Fatal("Err() not closed after Unsubscribe")
+	}
+
+	// A second Unsubscribe (directly or via the Notifier) must not panic by
+	// closing an already-closed channel.
+	sub.Unsubscribe()
+}
+
+func TestNotifierCloseAll(t *testing.T) {
+	n := newNotifier(&fakeNotificationWriter{})
+	sub1 := n.CreateSubscription("newHeads")
+	sub2 := n.CreateSubscription("logs")
+
+	n.closeAll()
+
+	for _, sub := range []*Subscription{sub1, sub2} {
+		select {
+		case <-sub.Err():
+		default:
+			t.Errorf("subscription %s not closed by closeAll", sub.ID)
+		}
+	}
+
+	// closeAll must also leave the Notifier safe to call Unsubscribe/Notify
+	// against afterwards, since they run concurrently with connection
+	// teardown.
+	n.Unsubscribe(sub1.ID)
+	if err := n.Notify(sub1.ID, 1); err != nil {
+		t.Fatalf("Notify after closeAll: %v", err)
+	}
+}
+
+func TestNotifierFromContext(t *testing.T) {
+	if _, ok := NotifierFromContext(context.Background()); ok {
+		t.Fatal("NotifierFromContext(context.Background()) = ok, want !ok")
+	}
+
+	n := newNotifier(&fakeNotificationWriter{})
+	ctx := context.WithValue(context.Background(), notifierContextKey{}, n)
+
+	got, ok := NotifierFromContext(ctx)
+	if !ok || got != n {
+		t.Fatalf("NotifierFromContext = %v, %v, want %v, true", got, ok, n)
+	}
+}
@@ -5,11 +5,13 @@
 package jsonrpc2
 
 import (
+	"bufio"
 	"encoding/json"
 	"errors"
 	"io"
 	"strings"
 	"sync"
+	"time"
 )
 
 const Version = "2.0"
@@ -17,6 +19,7 @@ const Version = "2.0"
 type serverCodec struct {
 	dec *json.Decoder // for reading JSON values
 	enc *json.Encoder // for writing JSON values
+	r   *bufio.Reader // underlying reader behind dec, so we can peek
 	c   io.ReadWriteCloser
 
 	// temporary work space
@@ -28,19 +31,62 @@ type serverCodec struct {
 	// but save the original request ID in the pending map.
 	// When rpc responds, we use the sequence number in
 	// the response to find the original request ID.
-	mutex   sync.Mutex // protects seq, pending
+	mutex   sync.Mutex // protects seq, pending, queue, batches
 	seq     uint64
 	pending map[uint64]*json.RawMessage
+
+	// batch support: elements of an in-flight batch request that have
+	// been decoded but not yet handed out via ReadRequestHeader, and the
+	// batch each dispatched request (by seq) belongs to, if any.
+	queue   []queuedRequest
+	batches map[uint64]batchMember
+
+	wmutex sync.Mutex // serializes writes to c/enc, including batch flushes
+
+	logger Logger
+	starts map[uint64]time.Time // request start times, for Logger durations
+}
+
+// queuedRequest is one element of a batch awaiting dispatch.
+type queuedRequest struct {
+	raw json.RawMessage
+	bw  *batchWriter
+	idx int
+}
+
+// batchMember records which batch (and position within it) a dispatched
+// request belongs to, so WriteResponse can route its result there instead
+// of writing it straight to the connection.
+type batchMember struct {
+	bw  *batchWriter
+	idx int
+}
+
+// Option configures a ServerCodec created by NewServerCodec.
+type Option func(*serverCodec)
+
+// WithLogger sets the Logger a ServerCodec reports request/response
+// lifecycle events to. The default is a no-op logger.
+func WithLogger(l Logger) Option {
+	return func(c *serverCodec) { c.logger = l }
 }
 
 // NewServerCodec returns a new ServerCodec using JSON-RPC on conn.
-func NewServerCodec(conn io.ReadWriteCloser) ServerCodec {
-	return &serverCodec{
-		dec:     json.NewDecoder(conn),
+func NewServerCodec(conn io.ReadWriteCloser, opts ...Option) ServerCodec {
+	r := bufio.NewReader(conn)
+	c := &serverCodec{
+		dec:     json.NewDecoder(r),
 		enc:     json.NewEncoder(conn),
+		r:       r,
 		c:       conn,
 		pending: make(map[uint64]*json.RawMessage),
+		logger:  noopLogger{},
+		starts:  make(map[uint64]time.Time),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
 type serverRequest struct {
@@ -65,32 +111,162 @@ type serverResponse struct {
 }
 
 func (c *serverCodec) ReadRequestHeader(r *Request) error {
-	c.req.reset()
-	if err := c.dec.Decode(&c.req); err != nil {
+	for {
+		c.mutex.Lock()
+		if len(c.queue) == 0 {
+			c.mutex.Unlock()
+			break
+		}
+		q := c.queue[0]
+		c.queue = c.queue[1:]
+		c.mutex.Unlock()
+
+		ok, err := c.readHeader(q.raw, q.bw, q.idx, r)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+
+	b, err := c.peekNonSpace()
+	if err != nil {
+		return err
+	}
+
+	if b != '[' {
+		var raw json.RawMessage
+		if err := c.dec.Decode(&raw); err != nil {
+			return err
+		}
+		_, err := c.readHeader(raw, nil, 0, r)
+		return err
+	}
+
+	var elems []json.RawMessage
+	if err := c.dec.Decode(&elems); err != nil {
 		return err
 	}
+	if len(elems) == 0 {
+		return &Error{
+			Code: ErrCodeInvalidReq,
+			Msg:  ErrMsgInvalidReq,
+		}
+	}
+
+	bw := newBatchWriter(len(elems), c.writeBatch)
+	queue := make([]queuedRequest, len(elems))
+	for i, raw := range elems {
+		queue[i] = queuedRequest{raw: raw, bw: bw, idx: i}
+	}
+
+	c.mutex.Lock()
+	c.queue = queue
+	c.mutex.Unlock()
+
+	return c.ReadRequestHeader(r)
+}
+
+// peekNonSpace returns the first non-whitespace byte of the next JSON value
+// without consuming it, so the caller can tell whether it's about to read a
+// single request object or a batch array.
+func (c *serverCodec) peekNonSpace() (byte, error) {
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b, c.r.UnreadByte()
+	}
+}
+
+// readHeader parses one JSON-RPC request object, either the sole top-level
+// request (bw == nil) or one element of a batch. For a standalone request,
+// a malformed object is reported back to the caller, which ServeCodec
+// treats as fatal for the connection. For a batch element, the error is
+// instead resolved immediately against bw and ok is false, so
+// ReadRequestHeader can move on to the rest of the batch.
+func (c *serverCodec) readHeader(raw json.RawMessage, bw *batchWriter, idx int, r *Request) (ok bool, err error) {
+	c.req.reset()
+	if err := json.Unmarshal(raw, &c.req); err != nil {
+		return c.batchError(bw, idx, err)
+	}
 	r.ServiceMethod = c.req.Method
 
+	// CancelMethod (e.g. "$/cancelRequest") is a package-level sentinel, not
+	// a "Type.Method" service call, so it's exempt from the dot check below;
+	// ServeCodecContext special-cases it instead of dispatching it.
 	dot := strings.LastIndex(r.ServiceMethod, ".")
-	if dot < 0 {
-		return &Error{
+	if dot < 0 && r.ServiceMethod != CancelMethod {
+		return c.batchError(bw, idx, &Error{
 			Code: ErrCodeInvalidReq,
 			Msg:  ErrMsgInvalidReq,
 			Data: "rpc: service/method request ill-formed: " + r.ServiceMethod,
-		}
+		})
 	}
 
+	reqID, reqParams := idOrNull(c.req.Id), paramsOrNull(c.req.Params)
+
 	// JSON request id can be any JSON value;
 	// RPC package expects uint64.  Translate to
 	// internal uint64 and save JSON on the side.
 	c.mutex.Lock()
 	c.seq++
-	c.pending[c.seq] = c.req.Id
+	seq := c.seq
+	c.pending[seq] = c.req.Id
+	c.starts[seq] = time.Now()
+	if bw != nil {
+		if c.batches == nil {
+			c.batches = make(map[uint64]batchMember)
+		}
+		c.batches[seq] = batchMember{bw: bw, idx: idx}
+	}
 	c.req.Id = nil
-	r.Seq = c.seq
+	r.Seq = seq
 	c.mutex.Unlock()
 
-	return nil
+	c.logger.LogRequest(r.ServiceMethod, reqID, reqParams)
+
+	return true, nil
+}
+
+func idOrNull(id *json.RawMessage) json.RawMessage {
+	if id == nil {
+		return null
+	}
+	return *id
+}
+
+func paramsOrNull(p *json.RawMessage) json.RawMessage {
+	if p == nil {
+		return null
+	}
+	return *p
+}
+
+// batchError reports err for a standalone request (bw == nil, fatal, left
+// to the caller) or resolves it as this batch element's response and tells
+// ReadRequestHeader to continue with the rest of the batch.
+func (c *serverCodec) batchError(bw *batchWriter, idx int, err error) (bool, error) {
+	if bw == nil {
+		return false, err
+	}
+
+	jerr, ok := err.(*Error)
+	if !ok {
+		jerr = &Error{Code: ErrCodeInvalidReq, Msg: ErrMsgInvalidReq}
+	}
+	raw, err := json.Marshal(serverResponse{Version: Version, Id: &null, Error: jerr})
+	if err != nil {
+		return false, err
+	}
+	rm := json.RawMessage(raw)
+	return false, bw.set(idx, &rm)
 }
 
 func (c *serverCodec) ReadRequestBody(x interface{}) error {
@@ -106,6 +282,7 @@ func (c *serverCodec) ReadRequestBody(x interface{}) error {
 
 	err := json.Unmarshal(*c.req.Params, &x)
 	if err != nil {
+		c.logger.LogError(err)
 		return &Error{
 			Code: ErrCodeParse,
 			Msg:  ErrMsgParse,
@@ -125,32 +302,94 @@ func (c *serverCodec) WriteResponse(r *Response, x interface{}) error {
 
 		// If there is an error, write it to response
 		if r.Error != nil {
+			c.logger.LogError(asError(r.Error))
 			resp := serverResponse{Version: Version, Id: &null}
 			resp.Error = r.Error
+			c.wmutex.Lock()
+			defer c.wmutex.Unlock()
 			return c.enc.Encode(resp)
 		}
 
 		return errors.New("invalid sequence number in response")
 	}
 	delete(c.pending, r.Seq)
+	member, inBatch := c.batches[r.Seq]
+	if inBatch {
+		delete(c.batches, r.Seq)
+	}
+	start, hadStart := c.starts[r.Seq]
+	delete(c.starts, r.Seq)
 	c.mutex.Unlock()
 
+	if hadStart {
+		c.logger.LogResponse(idOrNull(b), time.Since(start), asError(r.Error))
+	}
+
 	if b == nil {
 		// Request has no id which means it is a notification
 		// (http://www.jsonrpc.org/specification#notification)
+		if inBatch {
+			return member.bw.set(member.idx, nil)
+		}
 		// Return empty response
-		c.c.Write([]byte("\n"))
-		return nil
+		c.wmutex.Lock()
+		_, err := c.c.Write([]byte("\n"))
+		c.wmutex.Unlock()
+		return err
 	}
+
 	resp := serverResponse{Version: Version, Id: b}
 	if r.Error == nil {
 		resp.Result = x
 	} else {
 		resp.Error = r.Error
 	}
+
+	if inBatch {
+		raw, err := json.Marshal(resp)
+		if err != nil {
+			return err
+		}
+		rm := json.RawMessage(raw)
+		return member.bw.set(member.idx, &rm)
+	}
+
+	c.wmutex.Lock()
+	defer c.wmutex.Unlock()
 	return c.enc.Encode(resp)
 }
 
+// writeBatch flushes the collected responses of a completed batch request
+// as a single JSON array. A batch made up entirely of notifications has no
+// responses to report; like a lone notification, that's signalled with a
+// bare newline rather than an empty array so stream readers waiting on a
+// line don't block forever.
+func (c *serverCodec) writeBatch(responses []*json.RawMessage) error {
+	c.wmutex.Lock()
+	defer c.wmutex.Unlock()
+
+	if len(responses) == 0 {
+		_, err := c.c.Write([]byte("\n"))
+		return err
+	}
+	return c.enc.Encode(responses)
+}
+
+// WriteNotification writes an out-of-band server-to-client notification,
+// e.g. a subscription update pushed by a Notifier, guarded by the same
+// write mutex as ordinary responses and batch flushes so output can't
+// interleave mid-message.
+func (c *serverCodec) WriteNotification(method string, params interface{}) error {
+	c.wmutex.Lock()
+	defer c.wmutex.Unlock()
+
+	return c.enc.Encode(struct {
+		Version string      `json:"jsonrpc"`
+		Method  string      `json:"method"`
+		Params  interface{} `json:"params,omitempty"`
+	}{Version, method, params})
+}
+
 func (c *serverCodec) Close() error {
 	return c.c.Close()
 }
@@ -168,51 +168,45 @@ func TestServer(t *testing.T) {
 			true,
 		},
 
-		// // rpc call with an invalid Batch (but not empty):
-		// {
-		// 	`[1]`,
-		// 	`[
-		// 		{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"Invalid Request"}}
-		// 	]`,
-		// },
-		//
-		// // rpc call with invalid Batch:
-		// {
-		// 	`[1,2,3]`,
-		// 	`[
-		// 		{"jsonrpc": "2.0", "error": {"code": -32600, "message": "Invalid Request"}, "id": null},
-		// 		{"jsonrpc": "2.0", "error": {"code": -32600, "message": "Invalid Request"}, "id": null},
-		// 		{"jsonrpc": "2.0", "error": {"code": -32600, "message": "Invalid Request"}, "id": null}
-		// 	]`,
-		// },
-		//
-		// // rpc call Batch:
-		// {
-		// 	`[
-		// 		{"jsonrpc": "2.0", "method": "sum", "params": [1,2,4], "id": "1"},
-		// 		{"jsonrpc": "2.0", "method": "notify_hello", "params": [7]},
-		// 		{"jsonrpc": "2.0", "method": "subtract", "params": [42,23], "id": "2"},
-		// 		{"foo": "boo"},
-		// 		{"jsonrpc": "2.0", "method": "foo.get", "params": {"name": "myself"}, "id": "5"},
-		// 		{"jsonrpc": "2.0", "method": "get_data", "id": "9"}
-		// 	]`,
-		// 	`[
-		// 		{"jsonrpc": "2.0", "result": 7, "id": "1"},
-		// 		{"jsonrpc": "2.0", "result": 19, "id": "2"},
-		// 		{"jsonrpc": "2.0", "error": {"code": -32600, "message": "Invalid Request"}, "id": null},
-		// 		{"jsonrpc": "2.0", "error": {"code": -32601, "message": "Method not found"}, "id": "5"},
-		// 		{"jsonrpc": "2.0", "result": ["hello", 5], "id": "9"}
-		// 	]`,
-		// },
-		//
-		// // rpc call Batch (all notifications):
-		// {
-		// 	`[
-		// 		{"jsonrpc": "2.0", "method": "notify_sum", "params": [1,2,4]},
-		// 		{"jsonrpc": "2.0", "method": "notify_hello", "params": [7]}
-		// 	]`,
-		// 	``,
-		// },
+		// rpc call with an invalid Batch (but not empty): runs right after
+		// the empty-Array case above, whose header-level error is fatal for
+		// the connection like the other error cases above, so this one
+		// needs a fresh connection too.
+		{
+			`[1]`,
+			`[{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"Invalid Request"}}]`,
+			true,
+		},
+
+		// rpc call with invalid Batch:
+		{
+			`[1,2,3]`,
+			`[{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"Invalid Request"}},{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"Invalid Request"}},{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"Invalid Request"}}]`,
+			false,
+		},
+
+		// rpc call Batch:
+		{
+			`[
+				{"jsonrpc": "2.0", "method": "Arith.Sub", "params": [5,2], "id": "1"},
+				{"jsonrpc": "2.0", "method": "Notification.Update", "params": [7]},
+				{"jsonrpc": "2.0", "method": "Arith.Sub", "params": [42,23], "id": "2"},
+				{"foo": "boo"},
+				{"jsonrpc": "2.0", "method": "Arith.Foobar", "params": [1], "id": "5"}
+			]`,
+			`[{"jsonrpc":"2.0","id":"1","result":3},{"jsonrpc":"2.0","id":"2","result":19},{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"Invalid Request","data":"rpc: service/method request ill-formed: "}},{"jsonrpc":"2.0","id":"5","error":{"code":-32601,"message":"Method not found"}}]`,
+			false,
+		},
+
+		// rpc call Batch (all notifications):
+		{
+			`[
+				{"jsonrpc": "2.0", "method": "Notification.Update", "params": [1,2,4]},
+				{"jsonrpc": "2.0", "method": "Notification.FooBar", "params": [7]}
+			]`,
+			``,
+			false,
+		},
 	}
 
 	var cli, srv net.Conn
@@ -0,0 +1,67 @@
+package jsonrpc2
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// NewWebSocketHandler returns an http.Handler that upgrades each incoming
+// request with upgrader and serves JSON-RPC 2.0 over the resulting
+// connection via ServeConnContext, so the same RegisterWithContext
+// registry used by stdio and NewHTTPHandler is exposed over WebSocket too.
+// Unlike the newline-delimited framing ServeConn expects on a raw stream,
+// each JSON-RPC message here is carried in its own WebSocket frame.
+func NewWebSocketHandler(upgrader *websocket.Upgrader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		ServeConnContext(&wsConn{ws: ws})
+	})
+}
+
+// wsConn adapts a *websocket.Conn to io.ReadWriteCloser, reading and
+// writing one JSON-RPC message per WebSocket frame rather than relying on
+// newlines to separate them.
+type wsConn struct {
+	ws *websocket.Conn
+
+	wmutex sync.Mutex
+
+	rmutex  sync.Mutex
+	pending []byte // unread remainder of the current inbound frame
+}
+
+func (c *wsConn) Read(p []byte) (int, error) {
+	c.rmutex.Lock()
+	defer c.rmutex.Unlock()
+
+	for len(c.pending) == 0 {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+		c.pending = data
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *wsConn) Write(p []byte) (int, error) {
+	c.wmutex.Lock()
+	defer c.wmutex.Unlock()
+
+	if err := c.ws.WriteMessage(websocket.TextMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *wsConn) Close() error {
+	return c.ws.Close()
+}